@@ -0,0 +1,18 @@
+// Copyright (c) 2018 The VeChainThor developers
+
+// Distributed under the GNU Lesser General Public License v3.0 software license, see the accompanying
+// file LICENSE or <https://www.gnu.org/licenses/lgpl-3.0.html>
+
+package vm
+
+import "errors"
+
+// ErrConfidentialValueTransfer is the VMErr returned when a Call/StaticCall
+// targeting a confidential address, or a Create by a confidential caller,
+// carries VET. The public chain has no way to validate a balance it cannot
+// see, so such top-level value transfers are rejected outright; since
+// energy (VTHO) is only ever touched as a side effect of a value transfer
+// (see doTransfer in vm.go), rejecting the transfer also rejects the energy
+// touch. A value-carrying CALL issued by confidential bytecode is not
+// rejected with this error: it moves no balance and touches no energy.
+var ErrConfidentialValueTransfer = errors.New("vm: value transfer to/from confidential state is not allowed")
@@ -0,0 +1,54 @@
+// Copyright (c) 2018 The VeChainThor developers
+
+// Distributed under the GNU Lesser General Public License v3.0 software license, see the accompanying
+// file LICENSE or <https://www.gnu.org/licenses/lgpl-3.0.html>
+
+package vm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ForkConfig picks, at block level, which gas table is active for a VM
+// instance. It replaces the previous hard-coded chainConfig singleton
+// (every fork block pinned at 0) with caller-supplied values, so a hard
+// fork can be scheduled without recompiling thor.
+//
+// The block numbers below only cover the forks already known to
+// params.ChainConfig in this vendored go-ethereum version (up to
+// Byzantium). Selecting a later named gas table (Constantinople, Istanbul,
+// Berlin, London) requires a newer params.ChainConfig than this checkout
+// vendors.
+type ForkConfig struct {
+	HomesteadBlock uint32
+	EIP150Block    uint32
+	EIP150Hash     common.Hash
+	EIP155Block    uint32
+	EIP158Block    uint32
+	ByzantiumBlock uint32
+}
+
+// NoFork is the ForkConfig equivalent of the previous hard-coded
+// chainConfig: every fork active from genesis.
+var NoFork = ForkConfig{}
+
+// chainConfig builds a params.ChainConfig for fork, matching the layout of
+// the previous package-level chainConfig singleton.
+func (fork ForkConfig) chainConfig() *params.ChainConfig {
+	return &params.ChainConfig{
+		ChainId:        big.NewInt(0),
+		HomesteadBlock: new(big.Int).SetUint64(uint64(fork.HomesteadBlock)),
+		DAOForkBlock:   big.NewInt(0),
+		DAOForkSupport: false,
+		EIP150Block:    new(big.Int).SetUint64(uint64(fork.EIP150Block)),
+		EIP150Hash:     fork.EIP150Hash,
+		EIP155Block:    new(big.Int).SetUint64(uint64(fork.EIP155Block)),
+		EIP158Block:    new(big.Int).SetUint64(uint64(fork.EIP158Block)),
+		ByzantiumBlock: new(big.Int).SetUint64(uint64(fork.ByzantiumBlock)),
+		Ethash:         nil,
+		Clique:         nil,
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2018 The VeChainThor developers
+
+// Distributed under the GNU Lesser General Public License v3.0 software license, see the accompanying
+// file LICENSE or <https://www.gnu.org/licenses/lgpl-3.0.html>
+
+package vm
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/vechain/thor/thor"
+	"github.com/vechain/thor/vm/statedb"
+)
+
+// ErrPrecompileOutOfGas is the VMErr returned when the gas supplied to a
+// Call/StaticCall targeting a StatefulPrecompile is less than its
+// RequiredGas.
+var ErrPrecompileOutOfGas = errors.New("vm: out of gas")
+
+// ErrPrecompileWriteProtection is the VMErr returned when a StatefulPrecompile
+// attempts to emit an Event or Transfer during a StaticCall.
+var ErrPrecompileWriteProtection = errors.New("vm: write protection")
+
+// ErrInsufficientBalance is the VMErr returned when a Call carrying value
+// targets a StatefulPrecompile whose caller cannot cover the transfer.
+var ErrInsufficientBalance = errors.New("vm: insufficient balance for transfer")
+
+// PrecompileContext exposes the running call's context and state to a
+// StatefulPrecompile, so that Go-native precompiles can read/write thor
+// State directly instead of going through the opaque evm.StateDB.
+type PrecompileContext struct {
+	Context
+	State State
+
+	// ReadOnly reports whether the current call is a StaticCall. While it is
+	// true, EmitEvent, EmitTransfer and State.SetEnergy are discarded and
+	// the call fails with ErrPrecompileWriteProtection; other mutating
+	// methods of State are not intercepted (see readOnlyState).
+	ReadOnly bool
+
+	// mutated is set by EmitEvent/EmitTransfer so callPrecompile can turn a
+	// write attempted during a StaticCall into ErrPrecompileWriteProtection,
+	// the same way the interpreter rejects LOG/SSTORE in a static context.
+	mutated bool
+
+	emitEvent    func(*Event)
+	emitTransfer func(*Transfer)
+}
+
+// EmitEvent appends a contract log event to the output of the running call,
+// the same way LOG opcodes do for bytecode contracts. It is a no-op while
+// ReadOnly is set, matching LOG's rejection during STATICCALL.
+func (c *PrecompileContext) EmitEvent(event *Event) {
+	c.mutated = true
+	if c.ReadOnly {
+		return
+	}
+	if c.emitEvent != nil {
+		c.emitEvent(event)
+	}
+}
+
+// EmitTransfer records a token transfer performed by the precompile, the
+// same way value-transferring CALLs do for bytecode contracts. It is a
+// no-op while ReadOnly is set.
+func (c *PrecompileContext) EmitTransfer(transfer *Transfer) {
+	c.mutated = true
+	if c.ReadOnly {
+		return
+	}
+	if c.emitTransfer != nil {
+		c.emitTransfer(transfer)
+	}
+}
+
+// readOnlyState wraps a State so that SetEnergy calls a StatefulPrecompile
+// makes directly through PrecompileContext.State, rather than through
+// EmitEvent/EmitTransfer, are caught instead of silently mutating state
+// during a StaticCall. The embedded State's other methods (the opaque
+// statedb.State surface) are promoted unchanged: a precompile that reaches
+// a mutating method declared only there still writes directly, and is only
+// undone if that write happened to also be recorded in vm.stateDB's own
+// journal, which callPrecompile reverts on error.
+type readOnlyState struct {
+	State
+	mutated *bool
+}
+
+func (s readOnlyState) SetEnergy(addr thor.Address, energy *big.Int, blockTime uint64) {
+	*s.mutated = true
+}
+
+// StatefulPrecompile is a Go-implemented contract addressable at an
+// arbitrary thor.Address, registered through Config.Precompiles. Unlike the
+// fixed Ethereum precompile slots, a StatefulPrecompile has first-class
+// access to thor State via PrecompileContext.
+type StatefulPrecompile interface {
+	// Run executes the precompile against input, optionally moving value
+	// into the precompile's own balance. It must honor ctx.ReadOnly by
+	// returning an error instead of mutating State when set.
+	Run(ctx *PrecompileContext, input []byte, value *big.Int) ([]byte, error)
+
+	// RequiredGas returns the gas cost of running the precompile against
+	// input, charged before Run is invoked.
+	RequiredGas(input []byte) uint64
+}
+
+// newPrecompileContextFactory returns a constructor that builds a
+// PrecompileContext bound to ctx/state, emitting events and transfers
+// through stateDB so they flow into extractStateDBOutputs like any other
+// LOG opcode or value transfer.
+func newPrecompileContextFactory(ctx Context, state State, stateDB *statedb.StateDB) func(readOnly bool) *PrecompileContext {
+	return func(readOnly bool) *PrecompileContext {
+		pctx := &PrecompileContext{
+			Context:  ctx,
+			State:    state,
+			ReadOnly: readOnly,
+			emitEvent: func(event *Event) {
+				var topics []common.Hash
+				if len(event.Topics) > 0 {
+					topics = make([]common.Hash, 0, len(event.Topics))
+					for _, t := range event.Topics {
+						topics = append(topics, common.Hash(t))
+					}
+				}
+				stateDB.AddLog(&types.Log{
+					Address: common.Address(event.Address),
+					Topics:  topics,
+					Data:    event.Data,
+				})
+			},
+			emitTransfer: func(transfer *Transfer) {
+				stateDB.AddTransfer(&statedb.Transfer{
+					Sender:    transfer.Sender,
+					Recipient: transfer.Recipient,
+					Amount:    transfer.Amount,
+				})
+			},
+		}
+		if readOnly {
+			pctx.State = readOnlyState{State: state, mutated: &pctx.mutated}
+		}
+		return pctx
+	}
+}
+
+// callPrecompile dispatches a Call/StaticCall targeting a registered
+// StatefulPrecompile instead of bytecode: it charges RequiredGas, moves any
+// carried value into the precompile's balance, runs it against a
+// PrecompileContext bound to the live State, and rejects state mutation
+// attempted while readOnly is set. Like evm.Call does for bytecode
+// contracts, the value transfer and Run are wrapped in a stateDB snapshot
+// that gets rolled back if Run returns an error, so a reverted precompile
+// call leaves neither the caller debited nor its emitted events and
+// transfers in place. Writes a precompile makes directly through
+// PrecompileContext.State bypass that snapshot and are not rolled back.
+func (vm *VM) callPrecompile(precompile StatefulPrecompile, caller, addr thor.Address, input []byte, gas uint64, value *big.Int, readOnly bool) *Output {
+	if value == nil {
+		value = new(big.Int)
+	}
+
+	cost := precompile.RequiredGas(input)
+	if cost > gas {
+		return &Output{VMErr: ErrPrecompileOutOfGas}
+	}
+	leftOverGas := gas - cost
+
+	snapshot := vm.stateDB.Snapshot()
+
+	if value.Sign() != 0 {
+		if readOnly {
+			return &Output{LeftOverGas: leftOverGas, VMErr: ErrPrecompileWriteProtection}
+		}
+		if !canTransfer(vm.stateDB, common.Address(caller), value) {
+			return &Output{LeftOverGas: leftOverGas, VMErr: ErrInsufficientBalance}
+		}
+		vm.doTransfer(vm.stateDB, common.Address(caller), common.Address(addr), value)
+	}
+
+	pctx := vm.makeContext(readOnly)
+	ret, err := precompile.Run(pctx, input, value)
+	if err == nil && readOnly && pctx.mutated {
+		err = ErrPrecompileWriteProtection
+	}
+	if err != nil {
+		vm.stateDB.RevertToSnapshot(snapshot)
+	}
+
+	events, transfers, preimages := vm.extractStateDBOutputs()
+	confEvents, confTransfers := vm.extractConfidentialStateDBOutputs()
+	return &Output{ret, events, transfers, leftOverGas, vm.stateDB.GetRefund(), preimages, err, nil, confEvents, confTransfers}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2018 The VeChainThor developers
+
+// Distributed under the GNU Lesser General Public License v3.0 software license, see the accompanying
+// file LICENSE or <https://www.gnu.org/licenses/lgpl-3.0.html>
+
+package vm
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/vechain/thor/thor"
+)
+
+// errExecutionCancelled is reported to an attached Tracer's CaptureFault
+// when VM.Cancel interrupts a running call.
+var errExecutionCancelled = errors.New("execution cancelled")
+
+// Tracer is implemented by types that want to observe EVM execution:
+// every opcode step, subcall entry/exit, and the overall call outcome.
+// It is modeled on go-ethereum's vm.Tracer/EVMLogger so that trace data
+// collected here can be reshaped into the same RPC responses.
+//
+// VM drives CaptureStart/CaptureEnd around the outermost Call, StaticCall
+// and Create, and CaptureFault on VM.Cancel. CaptureState and CaptureEnter/
+// CaptureExit are driven per opcode and per bytecode-issued subcall
+// respectively, from inside the interpreter loop; this VM facade dispatches
+// at the Call/StaticCall/Create boundary and doesn't run that loop itself,
+// so an attached Tracer only ever sees the outermost frame.
+type Tracer interface {
+	// CaptureStart is called once before executing the outermost Call or
+	// StaticCall. For Create, VM doesn't know the new contract's address
+	// until after evm.Create has run, so CaptureStart necessarily fires
+	// after execution completes rather than before it, immediately ahead
+	// of CaptureEnd; a Tracer that relies on CaptureStart preceding every
+	// state change should not be attached to a VM used for Create.
+	CaptureStart(from, to thor.Address, create bool, input []byte, gas uint64, value *big.Int)
+
+	// CaptureState is called before executing each opcode.
+	CaptureState(pc uint64, op byte, gas, cost uint64, stack []*big.Int, memory []byte, contract thor.Address, depth int, err error)
+
+	// CaptureFault is called when execution fails, e.g. out-of-gas, an
+	// invalid opcode, or a cancelled VM.
+	CaptureFault(pc uint64, op byte, gas, cost uint64, depth int, err error)
+
+	// CaptureEnter is called when a subcall (CALL/CALLCODE/DELEGATECALL/
+	// STATICCALL/CREATE/CREATE2) begins.
+	CaptureEnter(op byte, from, to thor.Address, input []byte, gas uint64, value *big.Int)
+
+	// CaptureExit is called when a subcall started by CaptureEnter returns.
+	CaptureExit(output []byte, gasUsed uint64, err error)
+
+	// CaptureEnd is called once after the outermost call returns.
+	CaptureEnd(output []byte, gasUsed uint64, err error)
+}
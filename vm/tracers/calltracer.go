@@ -0,0 +1,137 @@
+// Copyright (c) 2018 The VeChainThor developers
+
+// Distributed under the GNU Lesser General Public License v3.0 software license, see the accompanying
+// file LICENSE or <https://www.gnu.org/licenses/lgpl-3.0.html>
+
+package tracers
+
+import (
+	"math/big"
+
+	"github.com/vechain/thor/thor"
+	"github.com/vechain/thor/vm"
+)
+
+// call-type opcodes recognized by CallTracer. Defined locally so this
+// package doesn't need to import vm/evm's full opcode table just to name
+// the handful of opcodes a call tree cares about.
+const (
+	opCall         byte = 0xf1
+	opCallCode     byte = 0xf2
+	opDelegateCall byte = 0xf4
+	opStaticCall   byte = 0xfa
+	opCreate       byte = 0xf0
+	opCreate2      byte = 0xf5
+)
+
+var callOpNames = map[byte]string{
+	opCall:         "CALL",
+	opCallCode:     "CALLCODE",
+	opDelegateCall: "DELEGATECALL",
+	opStaticCall:   "STATICCALL",
+	opCreate:       "CREATE",
+	opCreate2:      "CREATE2",
+}
+
+// CallFrame is one node of the call tree produced by CallTracer, in the
+// {type, from, to, value, gas, gasUsed, input, output, calls[]} shape used
+// by debug_traceTransaction's callTracer mode.
+type CallFrame struct {
+	Type    string       `json:"type"`
+	From    thor.Address `json:"from"`
+	To      thor.Address `json:"to"`
+	Value   *big.Int     `json:"value,omitempty"`
+	Gas     uint64       `json:"gas"`
+	GasUsed uint64       `json:"gasUsed"`
+	Input   []byte       `json:"input,omitempty"`
+	Output  []byte       `json:"output,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Calls   []*CallFrame `json:"calls,omitempty"`
+}
+
+// CallTracer is a vm.Tracer that assembles the call tree of an execution,
+// one CallFrame per CALL/CREATE family subcall. Against a real vm.VM
+// (see the package doc) it only ever sees the outermost frame, so
+// CallFrame().Calls is always empty; CaptureEnter/CaptureExit are exercised
+// here only by driving CallTracer directly, as calltracer_test.go does.
+type CallTracer struct {
+	root  *CallFrame
+	stack []*CallFrame
+}
+
+// NewCallTracer returns an empty CallTracer ready to be attached to a
+// vm.Config. Call CallFrame after the traced execution completes.
+func NewCallTracer() *CallTracer {
+	return &CallTracer{}
+}
+
+// CallFrame returns the root of the assembled call tree. It is nil until
+// CaptureStart has run.
+func (t *CallTracer) CallFrame() *CallFrame {
+	return t.root
+}
+
+// CaptureStart implements vm.Tracer.
+func (t *CallTracer) CaptureStart(from, to thor.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	typ := "CALL"
+	if create {
+		typ = "CREATE"
+	}
+	t.root = &CallFrame{Type: typ, From: from, To: to, Value: value, Gas: gas, Input: input}
+	t.stack = []*CallFrame{t.root}
+}
+
+// CaptureState implements vm.Tracer. CallTracer only cares about call
+// boundaries, so opcode steps are ignored.
+func (t *CallTracer) CaptureState(pc uint64, op byte, gas, cost uint64, stack []*big.Int, memory []byte, contract thor.Address, depth int, err error) {
+}
+
+// CaptureFault implements vm.Tracer by recording err on the current frame.
+func (t *CallTracer) CaptureFault(pc uint64, op byte, gas, cost uint64, depth int, err error) {
+	if len(t.stack) == 0 || err == nil {
+		return
+	}
+	t.stack[len(t.stack)-1].Error = err.Error()
+}
+
+// CaptureEnter implements vm.Tracer, pushing a child CallFrame for a subcall.
+func (t *CallTracer) CaptureEnter(op byte, from, to thor.Address, input []byte, gas uint64, value *big.Int) {
+	typ, ok := callOpNames[op]
+	if !ok {
+		typ = "CALL"
+	}
+	frame := &CallFrame{Type: typ, From: from, To: to, Value: value, Gas: gas, Input: input}
+	if len(t.stack) > 0 {
+		parent := t.stack[len(t.stack)-1]
+		parent.Calls = append(parent.Calls, frame)
+	}
+	t.stack = append(t.stack, frame)
+}
+
+// CaptureExit implements vm.Tracer, popping the current CallFrame.
+func (t *CallTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	if len(t.stack) == 0 {
+		return
+	}
+	frame := t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+	frame.GasUsed = gasUsed
+	frame.Output = output
+	if err != nil {
+		frame.Error = err.Error()
+	}
+}
+
+// CaptureEnd implements vm.Tracer, finalizing the root CallFrame.
+func (t *CallTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	if t.root == nil {
+		return
+	}
+	t.root.GasUsed = gasUsed
+	t.root.Output = output
+	if err != nil {
+		t.root.Error = err.Error()
+	}
+}
+
+var _ vm.Tracer = (*CallTracer)(nil)
@@ -0,0 +1,37 @@
+package tracers_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/thor/thor"
+	"github.com/vechain/thor/vm/tracers"
+)
+
+func TestCallTracer(t *testing.T) {
+	assert := assert.New(t)
+
+	from := thor.Address{1}
+	to := thor.Address{2}
+	inner := thor.Address{3}
+
+	tr := tracers.NewCallTracer()
+	tr.CaptureStart(from, to, false, []byte{0x01}, 100000, big.NewInt(10))
+	tr.CaptureEnter(0xf1, to, inner, []byte{0x02}, 50000, big.NewInt(0))
+	tr.CaptureExit([]byte{0x03}, 21000, nil)
+	tr.CaptureEnd([]byte{0x04}, 30000, nil)
+
+	root := tr.CallFrame()
+	assert.Equal("CALL", root.Type)
+	assert.Equal(from, root.From)
+	assert.Equal(to, root.To)
+	assert.Equal(uint64(30000), root.GasUsed)
+	assert.Equal([]byte{0x04}, root.Output)
+
+	assert.Len(root.Calls, 1)
+	child := root.Calls[0]
+	assert.Equal("CALL", child.Type)
+	assert.Equal(inner, child.To)
+	assert.Equal(uint64(21000), child.GasUsed)
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2018 The VeChainThor developers
+
+// Distributed under the GNU Lesser General Public License v3.0 software license, see the accompanying
+// file LICENSE or <https://www.gnu.org/licenses/lgpl-3.0.html>
+
+// Package tracers provides built-in vm.Tracer implementations that the API
+// layer can attach to vm.Config to serve trace RPCs without forking the VM.
+//
+// vm.VM only drives CaptureStart/CaptureEnd (and CaptureFault on Cancel);
+// it dispatches at the Call/StaticCall/Create boundary rather than running
+// an interpreter loop, so it never calls CaptureState or CaptureEnter/
+// CaptureExit. Against a real vm.VM, StructLogger therefore emits no
+// per-opcode struct logs (only the single entry its CaptureFault writes on
+// Cancel) and CallTracer's call tree has no children — both only reflect
+// the outermost frame until something drives per-opcode and per-subcall
+// tracing.
+package tracers
+
+import (
+	"encoding/json"
+	"io"
+	"math/big"
+
+	"github.com/vechain/thor/thor"
+	"github.com/vechain/thor/vm"
+)
+
+// structLog is one step of a StructLogger trace.
+type structLog struct {
+	Pc      uint64   `json:"pc"`
+	Op      byte     `json:"op"`
+	Gas     uint64   `json:"gas"`
+	GasCost uint64   `json:"gasCost"`
+	Depth   int      `json:"depth"`
+	Stack   []string `json:"stack,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// StructLogger is a vm.Tracer that streams a JSON object per executed
+// opcode to out, in the shape expected by debug_traceTransaction's
+// struct-log mode.
+type StructLogger struct {
+	out io.Writer
+	err error
+}
+
+// NewStructLogger returns a StructLogger that writes newline-delimited
+// JSON struct logs to out.
+func NewStructLogger(out io.Writer) *StructLogger {
+	return &StructLogger{out: out}
+}
+
+// CaptureStart implements vm.Tracer.
+func (l *StructLogger) CaptureStart(from, to thor.Address, create bool, input []byte, gas uint64, value *big.Int) {
+}
+
+// CaptureState implements vm.Tracer.
+func (l *StructLogger) CaptureState(pc uint64, op byte, gas, cost uint64, stack []*big.Int, memory []byte, contract thor.Address, depth int, err error) {
+	if l.err != nil {
+		return
+	}
+	entry := structLog{Pc: pc, Op: op, Gas: gas, GasCost: cost, Depth: depth}
+	if len(stack) > 0 {
+		entry.Stack = make([]string, len(stack))
+		for i, v := range stack {
+			entry.Stack[i] = v.String()
+		}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	data, encErr := json.Marshal(entry)
+	if encErr != nil {
+		l.err = encErr
+		return
+	}
+	data = append(data, '\n')
+	if _, werr := l.out.Write(data); werr != nil {
+		l.err = werr
+	}
+}
+
+// CaptureFault implements vm.Tracer by emitting a final struct log carrying err.
+func (l *StructLogger) CaptureFault(pc uint64, op byte, gas, cost uint64, depth int, err error) {
+	l.CaptureState(pc, op, gas, cost, nil, nil, thor.Address{}, depth, err)
+}
+
+// CaptureEnter implements vm.Tracer.
+func (l *StructLogger) CaptureEnter(op byte, from, to thor.Address, input []byte, gas uint64, value *big.Int) {
+}
+
+// CaptureExit implements vm.Tracer.
+func (l *StructLogger) CaptureExit(output []byte, gasUsed uint64, err error) {}
+
+// CaptureEnd implements vm.Tracer.
+func (l *StructLogger) CaptureEnd(output []byte, gasUsed uint64, err error) {}
+
+// Err returns the first error encountered while encoding or writing a struct
+// log, if any.
+func (l *StructLogger) Err() error {
+	return l.err
+}
+
+var _ vm.Tracer = (*StructLogger)(nil)
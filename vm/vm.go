@@ -16,8 +16,27 @@ import (
 	"github.com/vechain/thor/vm/statedb"
 )
 
-// Config is ref to evm.Config.
-type Config evm.Config
+// Config wraps evm.Config. Precompiles registers StatefulPrecompiles by
+// address; VM.Call/StaticCall consult this map before falling back to
+// bytecode dispatch and to the fixed Ethereum precompile slots. CALLs issued
+// by bytecode from inside the interpreter do not consult it. It is kept
+// outside evm.Config itself to avoid an import cycle between vm and evm.
+type Config struct {
+	evm.Config
+	Precompiles map[thor.Address]StatefulPrecompile
+
+	// Tracer, when set, observes the start and overall outcome of the
+	// outermost Call/StaticCall/Create, and a cancellation. Opcode steps and
+	// subcalls are not reported to it (see Tracer). It lives outside
+	// evm.Config for the same import-cycle reason as Precompiles.
+	Tracer Tracer
+
+	// ForkConfig selects, at block level, which gas table is active for
+	// this VM instance, replacing the previous hard-coded chainConfig.
+	// The zero value (NoFork) reproduces the old all-forks-at-genesis
+	// behavior.
+	ForkConfig ForkConfig
+}
 
 // Output contains the execution return value.
 type Output struct {
@@ -29,6 +48,12 @@ type Output struct {
 	Preimages       map[thor.Bytes32][]byte
 	VMErr           error         // VMErr identify the execution result of the contract function, not evm function's err.
 	ContractAddress *thor.Address // if create a new contract, or is nil.
+
+	// ConfidentialEvents and ConfidentialTransfers hold whatever Events and
+	// Transfers were produced against ConfidentialState during the call, so
+	// the node can persist them to a distinct index from the public ones.
+	ConfidentialEvents    []*Event
+	ConfidentialTransfers []*Transfer
 }
 
 // Event represents a contract log event. These events are generated by the LOG opcode and
@@ -60,20 +85,43 @@ type State interface {
 type VM struct {
 	evm     *evm.EVM
 	stateDB *statedb.StateDB
+
+	// makeContext builds a PrecompileContext for a StatefulPrecompile's Run,
+	// and precompiles holds the registry Call/StaticCall consult before
+	// falling back to bytecode dispatch.
+	makeContext func(readOnly bool) *PrecompileContext
+	precompiles map[thor.Address]StatefulPrecompile
+
+	// doTransfer moves value between two accounts, touching energy and
+	// recording a Transfer the same way evmCtx.Transfer does for
+	// value-carrying bytecode CALLs; Call/StaticCall reuse it when a value
+	// transfer targets a StatefulPrecompile.
+	doTransfer func(db evm.StateDB, sender, recipient common.Address, amount *big.Int)
+
+	tracer Tracer
+
+	// confidentialEvm/confidentialStateDB are non-nil when
+	// Context.ConfidentialState was set. Call/StaticCall route to them
+	// instead of evm/stateDB whenever isConfidential(addr) is true. Nested
+	// CALLs issued from within confidential bytecode back out to a public
+	// address are not re-routed here — doing so requires intercepting the
+	// CALL opcode inside the interpreter loop (vm/evm), which this checkout
+	// doesn't include; only the top-level dispatch performed by VM itself is
+	// enforced.
+	confidentialEvm     *evm.EVM
+	confidentialStateDB *statedb.StateDB
+	isConfidential      func(thor.Address) bool
 }
 
-var chainConfig = &params.ChainConfig{
-	ChainId:        big.NewInt(0),
-	HomesteadBlock: big.NewInt(0),
-	DAOForkBlock:   big.NewInt(0),
-	DAOForkSupport: false,
-	EIP150Block:    big.NewInt(0),
-	EIP150Hash:     common.Hash{},
-	EIP155Block:    big.NewInt(0),
-	EIP158Block:    big.NewInt(0),
-	ByzantiumBlock: big.NewInt(0),
-	Ethash:         nil,
-	Clique:         nil,
+// Precompiles returns the StatefulPrecompiles registered for this VM.
+func (vm *VM) Precompiles() map[thor.Address]StatefulPrecompile {
+	return vm.precompiles
+}
+
+// NewPrecompileContext builds a PrecompileContext bound to this VM's live
+// Context and State, for use by a registered StatefulPrecompile's Run.
+func (vm *VM) NewPrecompileContext(readOnly bool) *PrecompileContext {
+	return vm.makeContext(readOnly)
 }
 
 // Context for VM runtime.
@@ -91,6 +139,22 @@ type Context struct {
 	InterceptContractCall evm.InterceptContractCall
 	OnCreateContract      evm.OnCreateContract
 	OnSuicideContract     evm.OnSuicideContract
+
+	// ConfidentialState, when non-nil, is a second ledger for confidential
+	// contracts. IsConfidential decides, per call, whether the dispatcher
+	// should route SSTORE/SLOAD/balance access to ConfidentialState instead
+	// of the public State. A routed call runs entirely against
+	// ConfidentialState, so contract code is read from ConfidentialState as
+	// well; only block hashes come from the shared GetHash.
+	//
+	// Known limitation: IsConfidential is only consulted at the top-level
+	// Call/StaticCall/Create dispatch. A nested CALL issued by confidential
+	// bytecode back out to a public address keeps executing against
+	// ConfidentialState instead of being coerced into a public-state
+	// STATICCALL, because that coercion has to happen inside the
+	// interpreter's CALL handling, which this VM facade doesn't drive.
+	ConfidentialState State
+	IsConfidential     func(addr thor.Address) bool
 }
 
 // The only purpose of this func separate definition is to be compatible with evm.context.
@@ -108,27 +172,32 @@ func transfer(db evm.StateDB, sender, recipient common.Address, amount *big.Int)
 // only ever be used *once*.
 func New(ctx Context, state State, vmConfig Config) (vm *VM) {
 	stateDB := statedb.New(state)
+	var confidentialStateDB *statedb.StateDB
+	if ctx.ConfidentialState != nil {
+		confidentialStateDB = statedb.New(ctx.ConfidentialState)
+	}
+	doTransfer := func(db evm.StateDB, sender, recipient common.Address, amount *big.Int) {
+		if amount.Sign() == 0 {
+			return
+		}
+		// touch energy balance when token balance changed
+		// SHOULD be performed before transfer
+		state.SetEnergy(thor.Address(sender),
+			state.GetEnergy(thor.Address(sender), ctx.Time), ctx.Time)
+		state.SetEnergy(thor.Address(recipient),
+			state.GetEnergy(thor.Address(recipient), ctx.Time), ctx.Time)
+
+		transfer(db, sender, recipient, amount)
+
+		stateDB.AddTransfer(&statedb.Transfer{
+			Sender:    thor.Address(sender),
+			Recipient: thor.Address(recipient),
+			Amount:    amount,
+		})
+	}
 	evmCtx := evm.Context{
 		CanTransfer: canTransfer,
-		Transfer: func(db evm.StateDB, sender, recipient common.Address, amount *big.Int) {
-			if amount.Sign() == 0 {
-				return
-			}
-			// touch energy balance when token balance changed
-			// SHOULD be performed before transfer
-			state.SetEnergy(thor.Address(sender),
-				state.GetEnergy(thor.Address(sender), ctx.Time), ctx.Time)
-			state.SetEnergy(thor.Address(recipient),
-				state.GetEnergy(thor.Address(recipient), ctx.Time), ctx.Time)
-
-			transfer(db, sender, recipient, amount)
-
-			stateDB.AddTransfer(&statedb.Transfer{
-				Sender:    thor.Address(sender),
-				Recipient: thor.Address(recipient),
-				Amount:    amount,
-			})
-		},
+		Transfer:    doTransfer,
 		GetHash: func(n uint64) common.Hash {
 			return common.Hash(ctx.GetHash(uint32(n)))
 		},
@@ -147,25 +216,105 @@ func New(ctx Context, state State, vmConfig Config) (vm *VM) {
 		OnCreateContract:      ctx.OnCreateContract,
 		OnSuicideContract:     ctx.OnSuicideContract,
 	}
-	return &VM{
-		evm.NewEVM(evmCtx, stateDB, chainConfig, evm.Config(vmConfig)),
-		stateDB,
+	vmInstance := &VM{
+		evm:                 evm.NewEVM(evmCtx, stateDB, vmConfig.ForkConfig.chainConfig(), vmConfig.Config),
+		stateDB:             stateDB,
+		makeContext:         newPrecompileContextFactory(ctx, state, stateDB),
+		precompiles:         vmConfig.Precompiles,
+		doTransfer:          doTransfer,
+		tracer:              vmConfig.Tracer,
+		confidentialStateDB: confidentialStateDB,
+	}
+	if confidentialStateDB != nil {
+		confidentialEvmCtx := evmCtx
+		// Value transfers and energy touches against confidential state are
+		// rejected by VM.Call/StaticCall before dispatch reaches here; a
+		// nested CALL issued from confidential bytecode that still carries
+		// value simply moves no balance and touches no energy.
+		confidentialEvmCtx.Transfer = func(db evm.StateDB, sender, recipient common.Address, amount *big.Int) {}
+		vmInstance.confidentialEvm = evm.NewEVM(confidentialEvmCtx, confidentialStateDB, vmConfig.ForkConfig.chainConfig(), vmConfig.Config)
+		vmInstance.isConfidential = ctx.IsConfidential
 	}
+	return vmInstance
 }
 
 // Cancel cancels any running EVM operation.
 // This may be called concurrently and it's safe to be called multiple times.
+// If a Tracer is attached, it is notified via CaptureFault so trace output
+// doesn't silently end up truncated.
 func (vm *VM) Cancel() {
 	vm.evm.Cancel()
+	if vm.tracer != nil {
+		vm.tracer.CaptureFault(0, 0, 0, 0, 0, errExecutionCancelled)
+	}
 }
 
 // Call executes the contract associated with the addr with the given input as parameters.
 // It also handles any necessary value transfer required and takes the necessary steps to
 // create accounts and reverses the state in case of an execution error or failed value transfer.
 func (vm *VM) Call(caller thor.Address, addr thor.Address, input []byte, gas uint64, value *big.Int) *Output {
+	vm.captureStart(caller, addr, false, input, gas, value)
+	out := vm.call(caller, addr, input, gas, value)
+	vm.captureEnd(out.Data, gas-out.LeftOverGas, out.VMErr)
+	return out
+}
+
+func (vm *VM) call(caller thor.Address, addr thor.Address, input []byte, gas uint64, value *big.Int) *Output {
+	if vm.isConfidential != nil && vm.isConfidential(addr) {
+		return vm.callConfidential(caller, addr, input, gas, value, false)
+	}
+	if precompile, ok := vm.precompiles[addr]; ok {
+		return vm.callPrecompile(precompile, caller, addr, input, gas, value, false)
+	}
 	ret, leftOverGas, vmErr := vm.evm.Call(evm.AccountRef(caller), common.Address(addr), input, gas, value)
 	events, transfers, preimages := vm.extractStateDBOutputs()
-	return &Output{ret, events, transfers, leftOverGas, vm.stateDB.GetRefund(), preimages, vmErr, nil}
+	confEvents, confTransfers := vm.extractConfidentialStateDBOutputs()
+	return &Output{ret, events, transfers, leftOverGas, vm.stateDB.GetRefund(), preimages, vmErr, nil, confEvents, confTransfers}
+}
+
+// captureStart notifies an attached Tracer that the outermost Call,
+// StaticCall or Create is about to run. It is a no-op when no Tracer is
+// attached.
+func (vm *VM) captureStart(from, to thor.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	if vm.tracer == nil {
+		return
+	}
+	if value == nil {
+		value = new(big.Int)
+	}
+	vm.tracer.CaptureStart(from, to, create, input, gas, value)
+}
+
+// captureEnd notifies an attached Tracer that the outermost call returned.
+// It is a no-op when no Tracer is attached.
+func (vm *VM) captureEnd(output []byte, gasUsed uint64, err error) {
+	if vm.tracer == nil {
+		return
+	}
+	vm.tracer.CaptureEnd(output, gasUsed, err)
+}
+
+// callConfidential dispatches a Call/StaticCall targeting an address for
+// which Context.IsConfidential returned true: it rejects any carried value
+// (public chain can't validate a confidential balance) and executes against
+// ConfidentialState instead of the public State.
+func (vm *VM) callConfidential(caller, addr thor.Address, input []byte, gas uint64, value *big.Int, readOnly bool) *Output {
+	if value != nil && value.Sign() != 0 {
+		return &Output{VMErr: ErrConfidentialValueTransfer}
+	}
+
+	var (
+		ret         []byte
+		leftOverGas uint64
+		vmErr       error
+	)
+	if readOnly {
+		ret, leftOverGas, vmErr = vm.confidentialEvm.StaticCall(evm.AccountRef(caller), common.Address(addr), input, gas)
+	} else {
+		ret, leftOverGas, vmErr = vm.confidentialEvm.Call(evm.AccountRef(caller), common.Address(addr), input, gas, new(big.Int))
+	}
+	confEvents, confTransfers := vm.extractConfidentialStateDBOutputs()
+	return &Output{ret, nil, nil, leftOverGas, vm.confidentialStateDB.GetRefund(), nil, vmErr, nil, confEvents, confTransfers}
 }
 
 // StaticCall executes the contract associated with the addr with the given input as parameters
@@ -174,17 +323,66 @@ func (vm *VM) Call(caller thor.Address, addr thor.Address, input []byte, gas uin
 // Opcodes that attempt to perform such modifications will result in exceptions instead of performing
 // the modifications.
 func (vm *VM) StaticCall(caller thor.Address, addr thor.Address, input []byte, gas uint64) *Output {
+	vm.captureStart(caller, addr, false, input, gas, nil)
+	out := vm.staticCall(caller, addr, input, gas)
+	vm.captureEnd(out.Data, gas-out.LeftOverGas, out.VMErr)
+	return out
+}
+
+func (vm *VM) staticCall(caller thor.Address, addr thor.Address, input []byte, gas uint64) *Output {
+	if vm.isConfidential != nil && vm.isConfidential(addr) {
+		return vm.callConfidential(caller, addr, input, gas, nil, true)
+	}
+	if precompile, ok := vm.precompiles[addr]; ok {
+		return vm.callPrecompile(precompile, caller, addr, input, gas, nil, true)
+	}
 	ret, leftOverGas, vmErr := vm.evm.StaticCall(evm.AccountRef(caller), common.Address(addr), input, gas)
 	events, transfers, preimages := vm.extractStateDBOutputs()
-	return &Output{ret, events, transfers, leftOverGas, vm.stateDB.GetRefund(), preimages, vmErr, nil}
+	confEvents, confTransfers := vm.extractConfidentialStateDBOutputs()
+	return &Output{ret, events, transfers, leftOverGas, vm.stateDB.GetRefund(), preimages, vmErr, nil, confEvents, confTransfers}
 }
 
-// Create creates a new contract using code as deployment code.
+// Create creates a new contract using code as deployment code. If caller is
+// confidential (see Context.IsConfidential), the contract is created
+// against ConfidentialState instead of the public State, the same way
+// Call/StaticCall route confidential-targeted calls, and any carried value
+// is rejected since the public chain can't validate a confidential balance.
+//
+// See Tracer.CaptureStart for the one respect in which Create's tracing
+// differs from Call/StaticCall's.
 func (vm *VM) Create(caller thor.Address, code []byte, gas uint64, value *big.Int) *Output {
+	out := vm.create(caller, code, gas, value)
+	to := thor.Address{}
+	if out.ContractAddress != nil {
+		to = *out.ContractAddress
+	}
+	vm.captureStart(caller, to, true, code, gas, value)
+	vm.captureEnd(out.Data, gas-out.LeftOverGas, out.VMErr)
+	return out
+}
+
+func (vm *VM) create(caller thor.Address, code []byte, gas uint64, value *big.Int) *Output {
+	if vm.isConfidential != nil && vm.isConfidential(caller) {
+		return vm.createConfidential(caller, code, gas, value)
+	}
 	ret, contractAddr, leftOverGas, vmErr := vm.evm.Create(evm.AccountRef(caller), code, gas, value)
 	contractAddress := thor.Address(contractAddr)
 	events, transfers, preimages := vm.extractStateDBOutputs()
-	return &Output{ret, events, transfers, leftOverGas, vm.stateDB.GetRefund(), preimages, vmErr, &contractAddress}
+	confEvents, confTransfers := vm.extractConfidentialStateDBOutputs()
+	return &Output{ret, events, transfers, leftOverGas, vm.stateDB.GetRefund(), preimages, vmErr, &contractAddress, confEvents, confTransfers}
+}
+
+// createConfidential is Create's equivalent of callConfidential: it rejects
+// carried value and deploys against ConfidentialState via confidentialEvm
+// instead of the public evm.
+func (vm *VM) createConfidential(caller thor.Address, code []byte, gas uint64, value *big.Int) *Output {
+	if value != nil && value.Sign() != 0 {
+		return &Output{VMErr: ErrConfidentialValueTransfer}
+	}
+	ret, contractAddr, leftOverGas, vmErr := vm.confidentialEvm.Create(evm.AccountRef(caller), code, gas, new(big.Int))
+	contractAddress := thor.Address(contractAddr)
+	confEvents, confTransfers := vm.extractConfidentialStateDBOutputs()
+	return &Output{ret, nil, nil, leftOverGas, vm.confidentialStateDB.GetRefund(), nil, vmErr, &contractAddress, confEvents, confTransfers}
 }
 
 // ChainConfig returns the evmironment's chain configuration
@@ -218,6 +416,29 @@ func (vm *VM) extractStateDBOutputs() (
 	return
 }
 
+// extractConfidentialStateDBOutputs mirrors extractStateDBOutputs for
+// Context.ConfidentialState. It returns nil, nil when no ConfidentialState
+// was configured.
+func (vm *VM) extractConfidentialStateDBOutputs() (events []*Event, transfers []*Transfer) {
+	if vm.confidentialStateDB == nil {
+		return nil, nil
+	}
+	vm.confidentialStateDB.GetOutputs(
+		func(log *types.Log) bool {
+			events = append(events, ethlogToEvent(log))
+			return true
+		},
+		func(transfer *statedb.Transfer) bool {
+			transfers = append(transfers, (*Transfer)(transfer))
+			return true
+		},
+		func(key common.Hash, value []byte) bool {
+			return true
+		},
+	)
+	return
+}
+
 func ethlogToEvent(ethlog *types.Log) *Event {
 	var topics []thor.Bytes32
 	if len(ethlog.Topics) > 0 {
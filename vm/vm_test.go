@@ -0,0 +1,188 @@
+// Copyright (c) 2018 The VeChainThor developers
+
+// Distributed under the GNU Lesser General Public License v3.0 software license, see the accompanying
+// file LICENSE or <https://www.gnu.org/licenses/lgpl-3.0.html>
+
+package vm_test
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/thor/lvldb"
+	"github.com/vechain/thor/state"
+	"github.com/vechain/thor/thor"
+	"github.com/vechain/thor/vm"
+	"github.com/vechain/thor/vm/tracers"
+)
+
+// stubPrecompile is a minimal vm.StatefulPrecompile for exercising dispatch.
+type stubPrecompile struct {
+	gas uint64
+	run func(ctx *vm.PrecompileContext, input []byte, value *big.Int) ([]byte, error)
+}
+
+func (p *stubPrecompile) RequiredGas([]byte) uint64 { return p.gas }
+
+func (p *stubPrecompile) Run(ctx *vm.PrecompileContext, input []byte, value *big.Int) ([]byte, error) {
+	return p.run(ctx, input, value)
+}
+
+func newTestContext() vm.Context {
+	return vm.Context{
+		GasLimit: 1000000,
+		GasPrice: new(big.Int),
+		GetHash:  func(uint32) thor.Bytes32 { return thor.Bytes32{} },
+	}
+}
+
+// TestVMDrivesTracer checks that Call/StaticCall/Create themselves notify an
+// attached Tracer, rather than relying on a test that drives the Tracer
+// directly and never touches vm.VM.
+func TestVMDrivesTracer(t *testing.T) {
+	assert := assert.New(t)
+	kv, _ := lvldb.NewMem()
+	st, _ := state.New(thor.Hash{}, kv)
+
+	from := thor.Address{1}
+	to := thor.Address{2}
+
+	tr := tracers.NewCallTracer()
+	v := vm.New(newTestContext(), st, vm.Config{Tracer: tr})
+
+	v.Call(from, to, nil, 100000, new(big.Int))
+
+	frame := tr.CallFrame()
+	if assert.NotNil(frame, "CaptureStart should have been called by VM.Call") {
+		assert.Equal("CALL", frame.Type)
+		assert.Equal(from, frame.From)
+		assert.Equal(to, frame.To)
+	}
+}
+
+// TestPrecompileDispatch checks that Call/StaticCall actually consult
+// Config.Precompiles: charging RequiredGas, rejecting an uncovered value
+// transfer, reverting a failed Run's writes, and rejecting (and discarding)
+// a write attempted during StaticCall.
+func TestPrecompileDispatch(t *testing.T) {
+	assert := assert.New(t)
+	kv, _ := lvldb.NewMem()
+	st, _ := state.New(thor.Hash{}, kv)
+
+	from := thor.Address{1}
+	addr := thor.Address{9}
+
+	ok := &stubPrecompile{
+		gas: 100,
+		run: func(ctx *vm.PrecompileContext, input []byte, value *big.Int) ([]byte, error) {
+			ctx.EmitEvent(&vm.Event{Address: addr})
+			return []byte("ok"), nil
+		},
+	}
+	cfg := vm.Config{Precompiles: map[thor.Address]vm.StatefulPrecompile{addr: ok}}
+
+	v := vm.New(newTestContext(), st, cfg)
+	out := v.Call(from, addr, nil, 1000, new(big.Int))
+	assert.Nil(out.VMErr)
+	assert.Equal([]byte("ok"), out.Data)
+	assert.Equal(uint64(900), out.LeftOverGas)
+	assert.Len(out.Events, 1)
+
+	v = vm.New(newTestContext(), st, cfg)
+	out = v.Call(from, addr, nil, 50, new(big.Int))
+	assert.Equal(vm.ErrPrecompileOutOfGas, out.VMErr)
+
+	v = vm.New(newTestContext(), st, cfg)
+	out = v.Call(from, addr, nil, 1000, big.NewInt(1))
+	assert.Equal(vm.ErrInsufficientBalance, out.VMErr)
+
+	v = vm.New(newTestContext(), st, cfg)
+	out = v.StaticCall(from, addr, nil, 1000)
+	assert.Equal(vm.ErrPrecompileWriteProtection, out.VMErr)
+	assert.Empty(out.Events)
+
+	reverting := &stubPrecompile{
+		gas: 100,
+		run: func(ctx *vm.PrecompileContext, input []byte, value *big.Int) ([]byte, error) {
+			ctx.EmitEvent(&vm.Event{Address: addr})
+			return nil, errors.New("boom")
+		},
+	}
+	v = vm.New(newTestContext(), st, vm.Config{Precompiles: map[thor.Address]vm.StatefulPrecompile{addr: reverting}})
+	out = v.Call(from, addr, nil, 1000, new(big.Int))
+	assert.NotNil(out.VMErr)
+	assert.Empty(out.Events)
+}
+
+// TestConfidentialDispatch checks that Call/StaticCall/Create route a
+// confidential-targeted/confidential-caller invocation to ConfidentialState
+// instead of the public State, never touch public events/transfers while
+// doing so, and reject any carried value.
+func TestConfidentialDispatch(t *testing.T) {
+	assert := assert.New(t)
+	publicKV, _ := lvldb.NewMem()
+	publicState, _ := state.New(thor.Hash{}, publicKV)
+	confidentialKV, _ := lvldb.NewMem()
+	confidentialState, _ := state.New(thor.Hash{}, confidentialKV)
+
+	from := thor.Address{1}
+	confidentialAddr := thor.Address{7}
+
+	ctx := newTestContext()
+	ctx.ConfidentialState = confidentialState
+	ctx.IsConfidential = func(addr thor.Address) bool { return addr == confidentialAddr }
+
+	v := vm.New(ctx, publicState, vm.Config{})
+
+	out := v.Call(from, confidentialAddr, nil, 100000, new(big.Int))
+	assert.Nil(out.VMErr)
+	assert.Empty(out.Events)
+	assert.Empty(out.Transfers)
+
+	out = v.Call(from, confidentialAddr, nil, 100000, big.NewInt(1))
+	assert.Equal(vm.ErrConfidentialValueTransfer, out.VMErr)
+
+	out = v.StaticCall(from, confidentialAddr, nil, 100000)
+	assert.Nil(out.VMErr)
+
+	confidentialCtx := newTestContext()
+	confidentialCtx.ConfidentialState = confidentialState
+	confidentialCtx.IsConfidential = func(addr thor.Address) bool { return addr == from }
+	v = vm.New(confidentialCtx, publicState, vm.Config{})
+
+	out = v.Create(from, nil, 100000, big.NewInt(1))
+	assert.Equal(vm.ErrConfidentialValueTransfer, out.VMErr)
+}
+
+// TestForkConfigChainConfig checks that vm.NoFork reproduces the previous
+// hard-coded, all-forks-at-genesis chainConfig, and that a non-zero
+// ForkConfig changes the resulting VM.ChainConfig accordingly.
+func TestForkConfigChainConfig(t *testing.T) {
+	assert := assert.New(t)
+	kv, _ := lvldb.NewMem()
+	st, _ := state.New(thor.Hash{}, kv)
+
+	noForkVM := vm.New(newTestContext(), st, vm.Config{ForkConfig: vm.NoFork})
+	cc := noForkVM.ChainConfig()
+	assert.Equal(big.NewInt(0), cc.HomesteadBlock)
+	assert.Equal(big.NewInt(0), cc.EIP150Block)
+	assert.Equal(big.NewInt(0), cc.EIP155Block)
+	assert.Equal(big.NewInt(0), cc.EIP158Block)
+	assert.Equal(big.NewInt(0), cc.ByzantiumBlock)
+
+	forkedVM := vm.New(newTestContext(), st, vm.Config{ForkConfig: vm.ForkConfig{
+		HomesteadBlock: 1,
+		EIP150Block:    2,
+		EIP155Block:    3,
+		EIP158Block:    4,
+		ByzantiumBlock: 5,
+	}})
+	cc = forkedVM.ChainConfig()
+	assert.Equal(big.NewInt(1), cc.HomesteadBlock)
+	assert.Equal(big.NewInt(2), cc.EIP150Block)
+	assert.Equal(big.NewInt(3), cc.EIP155Block)
+	assert.Equal(big.NewInt(4), cc.EIP158Block)
+	assert.Equal(big.NewInt(5), cc.ByzantiumBlock)
+}